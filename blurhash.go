@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// blurHashXComponents and blurHashYComponents are the DCT component
+// counts used for -blurhash; 4x3 matches the density most blurhash
+// consumers (Mastodon, GoToSocial) expect for a thumbnail-sized
+// placeholder.
+const (
+	blurHashXComponents = 4
+	blurHashYComponents = 3
+)
+
+const base83Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// BlurHash encodes the image as a BlurHash string: a compact DCT-based
+// placeholder suitable for "loading" states (the format used by
+// Mastodon/GoToSocial attachments).
+func (ip *ImageProcessor) BlurHash(xComponents, yComponents int) (string, error) {
+	if xComponents < 1 || xComponents > 9 || yComponents < 1 || yComponents > 9 {
+		return "", fmt.Errorf("blurhash: component counts must be between 1 and 9")
+	}
+	if ip.width == 0 || ip.height == 0 {
+		return "", fmt.Errorf("blurhash: image has no pixels")
+	}
+
+	linear := make([][][3]float64, ip.height)
+	for y := 0; y < ip.height; y++ {
+		linear[y] = make([][3]float64, ip.width)
+		for x := 0; x < ip.width; x++ {
+			p := ip.pixels[y][x]
+			linear[y][x] = [3]float64{srgbToLinear(p.R), srgbToLinear(p.G), srgbToLinear(p.B)}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			factors = append(factors, blurHashBasisFactor(i, j, ip.width, ip.height, linear))
+		}
+	}
+	dc, ac := factors[0], factors[1:]
+
+	var hash strings.Builder
+	sizeFlag := (xComponents - 1) + (yComponents-1)*9
+	hash.WriteString(encode83(sizeFlag, 1))
+
+	maximumValue := 1.0
+	if len(ac) > 0 {
+		actualMaximumValue := 0.0
+		for _, f := range ac {
+			actualMaximumValue = math.Max(actualMaximumValue, math.Abs(f[0]))
+			actualMaximumValue = math.Max(actualMaximumValue, math.Abs(f[1]))
+			actualMaximumValue = math.Max(actualMaximumValue, math.Abs(f[2]))
+		}
+		quantisedMaximumValue := int(math.Max(0, math.Min(82, math.Floor(actualMaximumValue*166-0.5))))
+		maximumValue = float64(quantisedMaximumValue+1) / 166
+		hash.WriteString(encode83(quantisedMaximumValue, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+
+	hash.WriteString(encode83(encodeBlurHashDC(dc), 4))
+	for _, f := range ac {
+		hash.WriteString(encode83(encodeBlurHashAC(f, maximumValue), 2))
+	}
+
+	return hash.String(), nil
+}
+
+// blurHashBasisFactor computes the (i, j) DCT coefficient across the
+// whole image, per channel, in linear light.
+func blurHashBasisFactor(i, j, width, height int, linear [][][3]float64) [3]float64 {
+	normalisation := 2.0
+	if i == 0 && j == 0 {
+		normalisation = 1.0
+	}
+
+	var r, g, b float64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+				math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+			r += basis * linear[y][x][0]
+			g += basis * linear[y][x][1]
+			b += basis * linear[y][x][2]
+		}
+	}
+
+	scale := normalisation / float64(width*height)
+	return [3]float64{r * scale, g * scale, b * scale}
+}
+
+func encodeBlurHashDC(c [3]float64) int {
+	r := linearToSRGB(c[0])
+	g := linearToSRGB(c[1])
+	b := linearToSRGB(c[2])
+	return (r << 16) + (g << 8) + b
+}
+
+func encodeBlurHashAC(c [3]float64, maximumValue float64) int {
+	quant := func(v float64) int {
+		q := int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximumValue, 0.5)*9+9.5))))
+		return q
+	}
+	return quant(c[0])*19*19 + quant(c[1])*19 + quant(c[2])
+}
+
+func signPow(value, exp float64) float64 {
+	sign := 1.0
+	if value < 0 {
+		sign = -1.0
+	}
+	return sign * math.Pow(math.Abs(value), exp)
+}
+
+// srgbToLinear converts an 8-bit sRGB channel value to linear light.
+func srgbToLinear(c uint8) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB converts a linear-light value back to an 8-bit sRGB
+// channel, clamping to [0, 1] first.
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(math.Round(v * 12.92 * 255))
+	}
+	return int(math.Round((1.055*math.Pow(v, 1.0/2.4) - 0.055) * 255))
+}
+
+func encode83(value, length int) string {
+	result := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (value / pow83(length-i)) % 83
+		result[i-1] = base83Alphabet[digit]
+	}
+	return string(result)
+}
+
+func pow83(n int) int {
+	result := 1
+	for i := 0; i < n; i++ {
+		result *= 83
+	}
+	return result
+}
+
+// writeBlurHashSidecar writes a filename -> hash JSON map, the format
+// expected by -blurhash-out.
+func writeBlurHashSidecar(path string, hashes map[string]string) error {
+	data, err := json.MarshalIndent(hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+// TestBlurHashGolden pins the encoder's output for a small, fixed
+// gradient image. If this ever needs to change, regenerate it by
+// printing the actual value from a failing run -- a BlurHash is only
+// useful if it's stable across releases.
+func TestBlurHashGolden(t *testing.T) {
+	ip := newTestProcessor(8, 8)
+
+	got, err := ip.BlurHash(blurHashXComponents, blurHashYComponents)
+	if err != nil {
+		t.Fatalf("BlurHash() error = %v", err)
+	}
+
+	const want = "L00cGwSyWpodnRaza|jae;f7fQf7"
+	if got != want {
+		t.Errorf("BlurHash() = %q, want %q", got, want)
+	}
+}
+
+func TestBlurHashRejectsBadComponentCounts(t *testing.T) {
+	ip := newTestProcessor(4, 4)
+
+	if _, err := ip.BlurHash(0, 3); err == nil {
+		t.Error("BlurHash(0, 3) error = nil, want error")
+	}
+	if _, err := ip.BlurHash(4, 10); err == nil {
+		t.Error("BlurHash(4, 10) error = nil, want error")
+	}
+}
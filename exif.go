@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// defaultOrientation is the EXIF orientation value ("Horizontal (normal)")
+// assumed when a file carries no EXIF data at all.
+const defaultOrientation = 1
+
+const exifOrientationTag = 0x0112
+
+// readJPEGOrientation scans the JPEG marker segments in data for an APP1
+// EXIF block and returns the Orientation tag (1-8). It returns
+// defaultOrientation, nil when no EXIF data is present -- that's the normal
+// case for PNGs re-saved as JPEG, screenshots, etc., not an error.
+func readJPEGOrientation(data []byte) (int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return defaultOrientation, errors.New("exif: not a JPEG file")
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			break
+		}
+		marker := data[pos+1]
+
+		// Markers with no payload: SOI/EOI and the bare RST* markers.
+		if marker == 0xD8 || marker == 0xD9 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			pos += 2
+			continue
+		}
+		if marker == 0xDA {
+			// Start of scan -- compressed image data follows, no more markers.
+			break
+		}
+
+		segLen := int(data[pos+2])<<8 | int(data[pos+3])
+		segStart := pos + 4
+		segEnd := pos + 2 + segLen
+		if segLen < 2 || segEnd > len(data) {
+			return defaultOrientation, errors.New("exif: malformed marker segment")
+		}
+
+		if marker == 0xE1 {
+			if o, ok := parseEXIFOrientation(data[segStart:segEnd]); ok {
+				return o, nil
+			}
+		}
+
+		pos = segEnd
+	}
+
+	return defaultOrientation, nil
+}
+
+// parseEXIFOrientation reads the Orientation tag out of an APP1 payload
+// (the bytes between the segment length field and the next marker). It
+// returns ok=false if the payload isn't an Exif block or has no
+// Orientation entry, so the caller can fall back to the default.
+func parseEXIFOrientation(app1 []byte) (int, bool) {
+	if len(app1) < 6 || string(app1[:6]) != "Exif\x00\x00" {
+		return 0, false
+	}
+	tiff := app1[6:]
+	if len(tiff) < 8 {
+		return 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+	if order.Uint16(tiff[2:4]) != 0x002A {
+		return 0, false
+	}
+
+	ifdOffset := order.Uint32(tiff[4:8])
+	if int(ifdOffset)+2 > len(tiff) {
+		return 0, false
+	}
+
+	entryCount := order.Uint16(tiff[ifdOffset : ifdOffset+2])
+	base := int(ifdOffset) + 2
+	for i := 0; i < int(entryCount); i++ {
+		start := base + i*12
+		if start+12 > len(tiff) {
+			break
+		}
+		entry := tiff[start : start+12]
+		if order.Uint16(entry[0:2]) != exifOrientationTag {
+			continue
+		}
+		value := int(order.Uint16(entry[8:10]))
+		if value < 1 || value > 8 {
+			return defaultOrientation, true
+		}
+		return value, true
+	}
+
+	return 0, false
+}
+
+// buildEXIFOrientationSegment builds a minimal APP1 Exif payload carrying
+// a single Orientation entry, suitable for re-embedding into a JPEG that
+// has already been rotated into display orientation.
+func buildEXIFOrientationSegment(orientation int) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("Exif\x00\x00")
+	buf.WriteString("II")
+	binary.Write(buf, binary.LittleEndian, uint16(0x002A))
+	binary.Write(buf, binary.LittleEndian, uint32(8)) // IFD0 starts right after the TIFF header
+	binary.Write(buf, binary.LittleEndian, uint16(1)) // one entry
+	binary.Write(buf, binary.LittleEndian, uint16(exifOrientationTag))
+	binary.Write(buf, binary.LittleEndian, uint16(3)) // type SHORT
+	binary.Write(buf, binary.LittleEndian, uint32(1)) // count
+	binary.Write(buf, binary.LittleEndian, uint16(orientation))
+	binary.Write(buf, binary.LittleEndian, uint16(0)) // pad value field to 4 bytes
+	binary.Write(buf, binary.LittleEndian, uint32(0)) // no next IFD
+	return buf.Bytes()
+}
+
+// insertEXIFSegment inserts an APP1 EXIF segment right after the JPEG SOI
+// marker, ahead of any existing segments.
+func insertEXIFSegment(jpegData []byte, payload []byte) []byte {
+	segLen := len(payload) + 2 // length field covers itself but not the marker bytes
+	header := []byte{0xFF, 0xE1, byte(segLen >> 8), byte(segLen & 0xFF)}
+
+	out := make([]byte, 0, len(jpegData)+len(header)+len(payload))
+	out = append(out, jpegData[:2]...)
+	out = append(out, header...)
+	out = append(out, payload...)
+	out = append(out, jpegData[2:]...)
+	return out
+}
+
+// applyOrientation rotates/flips both the working and original pixel grids
+// so the image is stored in display orientation, then clears the stored
+// orientation since it no longer applies.
+func (ip *ImageProcessor) applyOrientation(orientation int) {
+	if orientation == defaultOrientation {
+		return
+	}
+
+	width, height := ip.width, ip.height
+	ip.pixels, ip.width, ip.height = orientGrid(ip.pixels, width, height, orientation)
+	ip.originalPixels, _, _ = orientGrid(ip.originalPixels, width, height, orientation)
+}
+
+func orientGrid(pixels [][]Pixel, width, height, orientation int) ([][]Pixel, int, int) {
+	switch orientation {
+	case 2:
+		return flipHorizontalGrid(pixels, width, height), width, height
+	case 3:
+		return rotate180Grid(pixels, width, height), width, height
+	case 4:
+		return flipVerticalGrid(pixels, width, height), width, height
+	case 5:
+		return transposeGrid(pixels, width, height), height, width
+	case 6:
+		return rotate90CWGrid(pixels, width, height), height, width
+	case 7:
+		return transverseGrid(pixels, width, height), height, width
+	case 8:
+		return rotate90CCWGrid(pixels, width, height), height, width
+	default:
+		return pixels, width, height
+	}
+}
+
+func rotate90CWGrid(pixels [][]Pixel, width, height int) [][]Pixel {
+	result := make([][]Pixel, width)
+	for i := range result {
+		result[i] = make([]Pixel, height)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			result[x][height-1-y] = pixels[y][x]
+		}
+	}
+	return result
+}
+
+func rotate90CCWGrid(pixels [][]Pixel, width, height int) [][]Pixel {
+	result := make([][]Pixel, width)
+	for i := range result {
+		result[i] = make([]Pixel, height)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			result[width-1-x][y] = pixels[y][x]
+		}
+	}
+	return result
+}
+
+func rotate180Grid(pixels [][]Pixel, width, height int) [][]Pixel {
+	result := make([][]Pixel, height)
+	for y := 0; y < height; y++ {
+		result[y] = make([]Pixel, width)
+		for x := 0; x < width; x++ {
+			result[y][x] = pixels[height-1-y][width-1-x]
+		}
+	}
+	return result
+}
+
+func flipHorizontalGrid(pixels [][]Pixel, width, height int) [][]Pixel {
+	result := make([][]Pixel, height)
+	for y := 0; y < height; y++ {
+		result[y] = make([]Pixel, width)
+		for x := 0; x < width; x++ {
+			result[y][x] = pixels[y][width-1-x]
+		}
+	}
+	return result
+}
+
+func flipVerticalGrid(pixels [][]Pixel, width, height int) [][]Pixel {
+	result := make([][]Pixel, height)
+	for y := 0; y < height; y++ {
+		result[y] = pixels[height-1-y]
+	}
+	return result
+}
+
+func transposeGrid(pixels [][]Pixel, width, height int) [][]Pixel {
+	result := make([][]Pixel, width)
+	for i := range result {
+		result[i] = make([]Pixel, height)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			result[x][y] = pixels[y][x]
+		}
+	}
+	return result
+}
+
+func transverseGrid(pixels [][]Pixel, width, height int) [][]Pixel {
+	return rotate180Grid(transposeGrid(pixels, width, height), height, width)
+}
+
+// RotateCW rotates the image 90 degrees clockwise. originalPixels is
+// rotated along with pixels so a later Reset() isn't left holding a
+// grid with the pre-rotation dimensions.
+func RotateCW(ip *ImageProcessor) {
+	ip.pixels = rotate90CWGrid(ip.pixels, ip.width, ip.height)
+	ip.originalPixels = rotate90CWGrid(ip.originalPixels, ip.width, ip.height)
+	ip.width, ip.height = ip.height, ip.width
+}
+
+// RotateCCW rotates the image 90 degrees counter-clockwise.
+func RotateCCW(ip *ImageProcessor) {
+	ip.pixels = rotate90CCWGrid(ip.pixels, ip.width, ip.height)
+	ip.originalPixels = rotate90CCWGrid(ip.originalPixels, ip.width, ip.height)
+	ip.width, ip.height = ip.height, ip.width
+}
+
+// FlipHorizontal mirrors the image left-to-right.
+func FlipHorizontal(ip *ImageProcessor) {
+	ip.pixels = flipHorizontalGrid(ip.pixels, ip.width, ip.height)
+	ip.originalPixels = flipHorizontalGrid(ip.originalPixels, ip.width, ip.height)
+}
+
+// FlipVertical mirrors the image top-to-bottom.
+func FlipVertical(ip *ImageProcessor) {
+	ip.pixels = flipVerticalGrid(ip.pixels, ip.width, ip.height)
+	ip.originalPixels = flipVerticalGrid(ip.originalPixels, ip.width, ip.height)
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+// TestApplyOrientationSwapsDimensions covers orientations 5-8, which
+// transpose the grid and therefore swap width/height -- the case that
+// used to panic on non-square images when originalPixels was oriented
+// against the already-swapped dimensions.
+func TestApplyOrientationSwapsDimensions(t *testing.T) {
+	tests := []struct {
+		orientation int
+		wantWidth   int
+		wantHeight  int
+	}{
+		{orientation: 1, wantWidth: 4, wantHeight: 2},
+		{orientation: 2, wantWidth: 4, wantHeight: 2},
+		{orientation: 3, wantWidth: 4, wantHeight: 2},
+		{orientation: 4, wantWidth: 4, wantHeight: 2},
+		{orientation: 5, wantWidth: 2, wantHeight: 4},
+		{orientation: 6, wantWidth: 2, wantHeight: 4},
+		{orientation: 7, wantWidth: 2, wantHeight: 4},
+		{orientation: 8, wantWidth: 2, wantHeight: 4},
+	}
+
+	for _, tt := range tests {
+		ip := newTestProcessor(4, 2)
+		ip.originalPixels = cloneGrid(ip.pixels)
+
+		ip.applyOrientation(tt.orientation)
+
+		if ip.width != tt.wantWidth || ip.height != tt.wantHeight {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", tt.orientation, ip.width, ip.height, tt.wantWidth, tt.wantHeight)
+		}
+		if len(ip.originalPixels) != ip.height || len(ip.originalPixels[0]) != ip.width {
+			t.Errorf("orientation %d: originalPixels size = %dx%d, want %dx%d", tt.orientation, len(ip.originalPixels[0]), len(ip.originalPixels), ip.width, ip.height)
+		}
+	}
+}
+
+// TestRotateCWKeepsOriginalPixelsInSync covers Reset() after a rotate,
+// which used to panic because originalPixels kept the pre-rotation
+// dimensions while pixels had already swapped width/height.
+func TestRotateCWKeepsOriginalPixelsInSync(t *testing.T) {
+	ip := newTestProcessor(4, 2)
+	ip.originalPixels = cloneGrid(ip.pixels)
+
+	RotateCW(ip)
+
+	if ip.width != 2 || ip.height != 4 {
+		t.Fatalf("size after RotateCW = %dx%d, want 2x4", ip.width, ip.height)
+	}
+
+	ip.Reset()
+
+	if len(ip.pixels) != 4 || len(ip.pixels[0]) != 2 {
+		t.Errorf("size after Reset = %dx%d, want 2x4", len(ip.pixels[0]), len(ip.pixels))
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// imageCodec pairs a decoder with an encoder for one format. encode is
+// nil for formats we can only read (WebP: the ecosystem has no mature
+// pure-Go encoder, so -output .webp isn't supported).
+type imageCodec struct {
+	decode func(io.Reader) (image.Image, error)
+	encode func(io.Writer, image.Image, int) error
+}
+
+// formatRegistry maps file extensions to codecs. GIF is intentionally
+// absent here -- it needs frame-aware handling and is dispatched
+// separately in NewImageProcessorWithOptions / saveAnimatedGIF.
+var formatRegistry = map[string]imageCodec{
+	".jpg":  {decode: jpeg.Decode, encode: encodeJPEGQuality},
+	".jpeg": {decode: jpeg.Decode, encode: encodeJPEGQuality},
+	".png":  {decode: png.Decode, encode: encodePNG},
+	".bmp":  {decode: bmp.Decode, encode: encodeBMP},
+	".tif":  {decode: tiff.Decode, encode: encodeTIFF},
+	".tiff": {decode: tiff.Decode, encode: encodeTIFF},
+	".webp": {decode: webp.Decode},
+	".gif":  {decode: gif.Decode, encode: encodeGIFStatic},
+}
+
+func encodeJPEGQuality(w io.Writer, img image.Image, quality int) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+func encodePNG(w io.Writer, img image.Image, _ int) error {
+	return png.Encode(w, img)
+}
+
+func encodeBMP(w io.Writer, img image.Image, _ int) error {
+	return bmp.Encode(w, img)
+}
+
+func encodeTIFF(w io.Writer, img image.Image, _ int) error {
+	return tiff.Encode(w, img, nil)
+}
+
+func encodeGIFStatic(w io.Writer, img image.Image, _ int) error {
+	return gif.Encode(w, img, nil)
+}
+
+// detectFormat sniffs magic bytes for files whose extension is missing
+// or doesn't match a registered format.
+func detectFormat(data []byte) string {
+	switch {
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xD8:
+		return ".jpg"
+	case len(data) >= 8 && bytes.Equal(data[:8], []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A}):
+		return ".png"
+	case len(data) >= 6 && (string(data[:6]) == "GIF87a" || string(data[:6]) == "GIF89a"):
+		return ".gif"
+	case len(data) >= 2 && data[0] == 'B' && data[1] == 'M':
+		return ".bmp"
+	case len(data) >= 4 && (bytes.Equal(data[:4], []byte{'I', 'I', 0x2A, 0x00}) || bytes.Equal(data[:4], []byte{'M', 'M', 0x00, 0x2A})):
+		return ".tiff"
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return ".webp"
+	default:
+		return ""
+	}
+}
+
+// pixelsFromImage copies img into a fresh [][]Pixel grid.
+func pixelsFromImage(img image.Image) ([][]Pixel, int, int) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	pixels := make([][]Pixel, height)
+	for y := 0; y < height; y++ {
+		pixels[y] = make([]Pixel, width)
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			pixels[y][x] = Pixel{
+				R: uint8(r >> 8),
+				G: uint8(g >> 8),
+				B: uint8(b >> 8),
+				A: uint8(a >> 8),
+			}
+		}
+	}
+	return pixels, width, height
+}
+
+func newImageProcessorFromImage(img image.Image) *ImageProcessor {
+	pixels, width, height := pixelsFromImage(img)
+	original := make([][]Pixel, height)
+	for y := range pixels {
+		original[y] = make([]Pixel, width)
+		copy(original[y], pixels[y])
+	}
+	return &ImageProcessor{
+		width:          width,
+		height:         height,
+		pixels:         pixels,
+		originalPixels: original,
+	}
+}
+
+func pixelsToRGBA(pixels [][]Pixel, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := pixels[y][x]
+			img.Set(x, y, color.RGBA{p.R, p.G, p.B, p.A})
+		}
+	}
+	return img
+}
+
+func cloneGrid(pixels [][]Pixel) [][]Pixel {
+	clone := make([][]Pixel, len(pixels))
+	for y, row := range pixels {
+		clone[y] = make([]Pixel, len(row))
+		copy(clone[y], row)
+	}
+	return clone
+}
+
+// newImageProcessorFromGIF decodes a GIF. When processAllFrames is
+// false, or the GIF only has one frame, it behaves like any other
+// single-frame format. Otherwise every frame is composited onto a
+// full-size canvas (frames can cover only part of the image and rely
+// on previous frames showing through) and kept in ip.frames.
+func newImageProcessorFromGIF(data []byte, processAllFrames bool) (*ImageProcessor, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := g.Image[0].Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), g.Image[0], bounds.Min, draw.Src)
+
+	if !processAllFrames || len(g.Image) == 1 {
+		return newImageProcessorFromImage(canvas), nil
+	}
+
+	width, height := canvas.Bounds().Dx(), canvas.Bounds().Dy()
+	ip := &ImageProcessor{width: width, height: height, loopCount: g.LoopCount}
+
+	for i, frameImg := range g.Image {
+		draw.Draw(canvas, frameImg.Bounds(), frameImg, frameImg.Bounds().Min, draw.Over)
+
+		framePixels, _, _ := pixelsFromImage(canvas)
+		ip.frames = append(ip.frames, framePixels)
+		ip.frameDelays = append(ip.frameDelays, g.Delay[i])
+
+		if i < len(g.Disposal) && g.Disposal[i] == gif.DisposalBackground {
+			canvas = image.NewRGBA(image.Rect(0, 0, width, height))
+		}
+	}
+
+	ip.pixels = ip.frames[0]
+	ip.originalPixels = cloneGrid(ip.pixels)
+	return ip, nil
+}
+
+// forEachFrame runs fn once per animation frame, or once on ip itself
+// if it isn't animated. Results are written back into ip.frames and
+// ip.pixels/width/height are synced to frame 0 afterward, so callers
+// that only care about the single-frame case don't need to change.
+func (ip *ImageProcessor) forEachFrame(fn func(*ImageProcessor)) {
+	if len(ip.frames) == 0 {
+		fn(ip)
+		return
+	}
+
+	width, height := ip.width, ip.height
+	for i, frame := range ip.frames {
+		tmp := &ImageProcessor{
+			width:          width,
+			height:         height,
+			pixels:         frame,
+			originalPixels: frame,
+		}
+		fn(tmp)
+		ip.frames[i] = tmp.pixels
+		ip.width, ip.height = tmp.width, tmp.height
+	}
+
+	ip.pixels = ip.frames[0]
+	ip.originalPixels = ip.frames[0]
+}
+
+// saveAnimatedGIF re-encodes every frame with its original delay and
+// loop count, quantizing each frame to a fixed palette (GIF requires
+// paletted frames).
+func (ip *ImageProcessor) saveAnimatedGIF(path string) error {
+	g := &gif.GIF{LoopCount: ip.loopCount}
+
+	for i, frame := range ip.frames {
+		rgba := pixelsToRGBA(frame, ip.width, ip.height)
+		paletted := image.NewPaletted(rgba.Bounds(), palette.Plan9)
+		draw.Draw(paletted, paletted.Bounds(), rgba, image.Point{}, draw.Src)
+
+		delay := 10
+		if i < len(ip.frameDelays) {
+			delay = ip.frameDelays[i]
+		}
+		g.Image = append(g.Image, paletted)
+		g.Delay = append(g.Delay, delay)
+	}
+
+	output, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	return gif.EncodeAll(output, g)
+}
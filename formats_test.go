@@ -0,0 +1,91 @@
+package main
+
+import (
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFormatRoundTrip saves a processor through each non-JPEG codec in
+// formatRegistry and reloads it, checking the decoded size matches.
+func TestFormatRoundTrip(t *testing.T) {
+	exts := []string{".bmp", ".tif", ".gif"}
+
+	for _, ext := range exts {
+		t.Run(ext, func(t *testing.T) {
+			ip := newTestProcessor(6, 4)
+			path := filepath.Join(t.TempDir(), "roundtrip"+ext)
+
+			if err := ip.SaveImage(path); err != nil {
+				t.Fatalf("SaveImage(%s) error = %v", ext, err)
+			}
+
+			loaded, err := NewImageProcessorWithOptions(path, false)
+			if err != nil {
+				t.Fatalf("NewImageProcessorWithOptions(%s) error = %v", ext, err)
+			}
+			if loaded.width != 6 || loaded.height != 4 {
+				t.Errorf("%s round-trip size = %dx%d, want 6x4", ext, loaded.width, loaded.height)
+			}
+		})
+	}
+}
+
+// TestAnimatedGIFFrameCount covers loading a multi-frame GIF both with
+// and without ProcessAllFrames: the former should keep every frame (and
+// its delay), the latter should flatten to the first frame only.
+func TestAnimatedGIFFrameCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anim.gif")
+	writeTestAnimatedGIF(t, path, 3)
+
+	withFrames, err := NewImageProcessorWithOptions(path, true)
+	if err != nil {
+		t.Fatalf("NewImageProcessorWithOptions(ProcessAllFrames=true) error = %v", err)
+	}
+	if len(withFrames.frames) != 3 {
+		t.Errorf("len(frames) = %d, want 3", len(withFrames.frames))
+	}
+	if len(withFrames.frameDelays) != 3 {
+		t.Errorf("len(frameDelays) = %d, want 3", len(withFrames.frameDelays))
+	}
+
+	firstOnly, err := NewImageProcessorWithOptions(path, false)
+	if err != nil {
+		t.Fatalf("NewImageProcessorWithOptions(ProcessAllFrames=false) error = %v", err)
+	}
+	if len(firstOnly.frames) != 0 {
+		t.Errorf("len(frames) = %d, want 0 when not processing all frames", len(firstOnly.frames))
+	}
+	if firstOnly.width != 6 || firstOnly.height != 4 {
+		t.Errorf("single-frame size = %dx%d, want 6x4", firstOnly.width, firstOnly.height)
+	}
+}
+
+func writeTestAnimatedGIF(t *testing.T, path string, frameCount int) {
+	t.Helper()
+
+	g := &gif.GIF{}
+	bounds := image.Rect(0, 0, 6, 4)
+	for i := 0; i < frameCount; i++ {
+		rgba := image.NewRGBA(bounds)
+		draw.Draw(rgba, bounds, &image.Uniform{C: palette.Plan9[i%len(palette.Plan9)]}, image.Point{}, draw.Src)
+		paletted := image.NewPaletted(bounds, palette.Plan9)
+		draw.Draw(paletted, bounds, rgba, image.Point{}, draw.Src)
+		g.Image = append(g.Image, paletted)
+		g.Delay = append(g.Delay, 10)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("os.Create: %v", err)
+	}
+	defer f.Close()
+
+	if err := gif.EncodeAll(f, g); err != nil {
+		t.Fatalf("gif.EncodeAll: %v", err)
+	}
+}
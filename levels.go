@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+)
+
+// Histogram computes per-channel (R, G, B) pixel-value distributions.
+func (ip *ImageProcessor) Histogram() [3][256]int {
+	var hist [3][256]int
+	for y := 0; y < ip.height; y++ {
+		for x := 0; x < ip.width; x++ {
+			p := ip.pixels[y][x]
+			hist[0][p.R]++
+			hist[1][p.G]++
+			hist[2][p.B]++
+		}
+	}
+	return hist
+}
+
+// AutoLevelsOptions configures the percentile clipping used by
+// AutoLevelsWithOptions. BlackClip/WhiteClip are percentages (0-100) of
+// pixels allowed to clip at each end.
+type AutoLevelsOptions struct {
+	BlackClip float64
+	WhiteClip float64
+}
+
+// AutoLevels stretches each channel independently so its 1st/99th
+// percentiles map to 0/255. Use AutoLevelsWithOptions to pick different
+// clip percentages.
+func (ip *ImageProcessor) AutoLevels() {
+	ip.AutoLevelsWithOptions(AutoLevelsOptions{BlackClip: 1, WhiteClip: 1})
+}
+
+func (ip *ImageProcessor) AutoLevelsWithOptions(opts AutoLevelsOptions) {
+	hist := ip.Histogram()
+	total := ip.width * ip.height
+
+	var low, high [3]uint8
+	for c := 0; c < 3; c++ {
+		low[c], high[c] = percentileBounds(hist[c], total, opts.BlackClip, opts.WhiteClip)
+	}
+
+	for y := 0; y < ip.height; y++ {
+		for x := 0; x < ip.width; x++ {
+			p := ip.pixels[y][x]
+			ip.pixels[y][x] = Pixel{
+				R: stretchChannel(p.R, low[0], high[0]),
+				G: stretchChannel(p.G, low[1], high[1]),
+				B: stretchChannel(p.B, low[2], high[2]),
+				A: p.A,
+			}
+		}
+	}
+}
+
+// AutoContrast stretches luminance to the 1st/99th percentiles and
+// applies the same scale to all channels, preserving hue (unlike
+// AutoLevels, which stretches each channel independently).
+func (ip *ImageProcessor) AutoContrast() {
+	var lumaHist [256]int
+	for y := 0; y < ip.height; y++ {
+		for x := 0; x < ip.width; x++ {
+			p := ip.pixels[y][x]
+			luma := uint8(float64(p.R)*0.299 + float64(p.G)*0.587 + float64(p.B)*0.114)
+			lumaHist[luma]++
+		}
+	}
+
+	low, high := percentileBounds(lumaHist, ip.width*ip.height, 1, 1)
+	if high <= low {
+		return
+	}
+
+	for y := 0; y < ip.height; y++ {
+		for x := 0; x < ip.width; x++ {
+			p := ip.pixels[y][x]
+			ip.pixels[y][x] = Pixel{
+				R: stretchChannel(p.R, low, high),
+				G: stretchChannel(p.G, low, high),
+				B: stretchChannel(p.B, low, high),
+				A: p.A,
+			}
+		}
+	}
+}
+
+// percentileBounds finds the channel values below which blackClipPercent
+// of pixels fall, and above which whiteClipPercent fall.
+func percentileBounds(hist [256]int, total int, blackClipPercent, whiteClipPercent float64) (uint8, uint8) {
+	blackCount := int(float64(total) * blackClipPercent / 100)
+	whiteCount := int(float64(total) * whiteClipPercent / 100)
+
+	low := 0
+	cum := 0
+	for v := 0; v < 256; v++ {
+		cum += hist[v]
+		if cum > blackCount {
+			low = v
+			break
+		}
+	}
+
+	high := 255
+	cum = 0
+	for v := 255; v >= 0; v-- {
+		cum += hist[v]
+		if cum > whiteCount {
+			high = v
+			break
+		}
+	}
+
+	if high <= low {
+		return 0, 255
+	}
+	return uint8(low), uint8(high)
+}
+
+func stretchChannel(v, low, high uint8) uint8 {
+	if high <= low {
+		return v
+	}
+	scaled := (float64(v) - float64(low)) / (float64(high) - float64(low)) * 255
+	return clamp(scaled)
+}
+
+// Gamma applies out = 255 * (in/255)^(1/g) per channel via a
+// precomputed 256-entry lookup table.
+func (ip *ImageProcessor) Gamma(g float64) {
+	if g <= 0 {
+		return
+	}
+
+	var lut [256]uint8
+	for i := 0; i < 256; i++ {
+		lut[i] = clamp(255 * math.Pow(float64(i)/255, 1/g))
+	}
+
+	for y := 0; y < ip.height; y++ {
+		for x := 0; x < ip.width; x++ {
+			p := ip.pixels[y][x]
+			ip.pixels[y][x] = Pixel{R: lut[p.R], G: lut[p.G], B: lut[p.B], A: p.A}
+		}
+	}
+}
+
+type histogramDump struct {
+	Before [3][256]int `json:"before"`
+	After  [3][256]int `json:"after"`
+}
+
+// writeHistogramDebug writes the pre- and post-processing histograms
+// requested by -histogram, for scripting pipelines that want to
+// inspect what an effect chain actually did.
+func writeHistogramDebug(path string, before, after [3][256]int) error {
+	data, err := json.MarshalIndent(histogramDump{Before: before, After: after}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
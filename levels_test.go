@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestGammaIdentity(t *testing.T) {
+	ip := newTestProcessor(4, 4)
+	before := cloneGrid(ip.pixels)
+
+	ip.Gamma(1)
+
+	for y := range ip.pixels {
+		for x := range ip.pixels[y] {
+			if ip.pixels[y][x] != before[y][x] {
+				t.Fatalf("Gamma(1) changed pixel (%d,%d): got %+v, want %+v", x, y, ip.pixels[y][x], before[y][x])
+			}
+		}
+	}
+}
+
+func TestAutoContrastStretchesLuminance(t *testing.T) {
+	// A flat mid-gray image has no spread to stretch, so AutoContrast
+	// should leave it untouched rather than divide by a zero range.
+	ip := &ImageProcessor{width: 2, height: 2, pixels: [][]Pixel{
+		{{R: 128, G: 128, B: 128, A: 255}, {R: 128, G: 128, B: 128, A: 255}},
+		{{R: 128, G: 128, B: 128, A: 255}, {R: 128, G: 128, B: 128, A: 255}},
+	}}
+
+	ip.AutoContrast()
+
+	for _, row := range ip.pixels {
+		for _, p := range row {
+			if p.R != 128 || p.G != 128 || p.B != 128 {
+				t.Errorf("AutoContrast on flat image changed pixel to %+v", p)
+			}
+		}
+	}
+}
@@ -1,24 +1,32 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
-	"image"
-	"image/color"
 	"image/jpeg"
-	"image/png"
 	"log"
 	"math"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 )
 
 type ImageProcessor struct {
-	width         int
-	height        int
-	pixels        [][]Pixel
+	width          int
+	height         int
+	pixels         [][]Pixel
 	originalPixels [][]Pixel
+
+	// frames, frameDelays (in 1/100s, per the GIF spec), and loopCount
+	// are only populated for an animated GIF loaded with
+	// ProcessAllFrames set. pixels always mirrors frames[0] so
+	// single-frame code paths keep working unchanged.
+	frames      [][][]Pixel
+	frameDelays []int
+	loopCount   int
 }
 
 type Pixel struct {
@@ -44,71 +52,113 @@ func (ip *ImageProcessor) Clone() *ImageProcessor {
 		copy(newPixels[i], ip.pixels[i])
 	}
 	return &ImageProcessor{
-		width:         ip.width,
-		height:        ip.height,
-		pixels:        newPixels,
+		width:          ip.width,
+		height:         ip.height,
+		pixels:         newPixels,
 		originalPixels: ip.originalPixels,
 	}
 }
 
+// NewImageProcessor loads path for single-frame processing. Animated
+// GIFs are flattened to their first frame; use NewImageProcessorWithOptions
+// with ProcessAllFrames to keep every frame.
 func NewImageProcessor(path string) (*ImageProcessor, error) {
-	file, err := os.Open(path)
+	return NewImageProcessorWithOptions(path, false)
+}
+
+// NewImageProcessorWithOptions loads path, dispatching on its registered
+// format (see formats.go). If the extension isn't recognized, it falls
+// back to sniffing the file's magic bytes. processAllFrames only
+// affects animated GIFs.
+func NewImageProcessorWithOptions(path string, processAllFrames bool) (*ImageProcessor, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	var img image.Image
-	ext := filepath.Ext(path)
-	switch ext {
-	case ".jpg", ".jpeg":
-		img, err = jpeg.Decode(file)
-	case ".png":
-		img, err = png.Decode(file)
-	default:
-		return nil, err
+	ext := strings.ToLower(filepath.Ext(path))
+	if _, ok := formatRegistry[ext]; !ok {
+		if detected := detectFormat(data); detected != "" {
+			ext = detected
+		}
 	}
-	if err != nil {
-		return nil, err
+
+	if ext == ".gif" {
+		return newImageProcessorFromGIF(data, processAllFrames)
 	}
 
-	bounds := img.Bounds()
-	width, height := bounds.Max.X, bounds.Max.Y
-	pixels := make([][]Pixel, height)
-	originalPixels := make([][]Pixel, height)
-	
-	for y := 0; y < height; y++ {
-		pixels[y] = make([]Pixel, width)
-		originalPixels[y] = make([]Pixel, width)
-		for x := 0; x < width; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
-			pixel := Pixel{
-				R: uint8(r >> 8),
-				G: uint8(g >> 8),
-				B: uint8(b >> 8),
-				A: uint8(a >> 8),
-			}
-			pixels[y][x] = pixel
-			originalPixels[y][x] = pixel
+	codec, ok := formatRegistry[ext]
+	if !ok {
+		return nil, fmt.Errorf("unsupported image format: %s", filepath.Ext(path))
+	}
+
+	orientation := defaultOrientation
+	if ext == ".jpg" || ext == ".jpeg" {
+		if o, err := readJPEGOrientation(data); err == nil {
+			orientation = o
 		}
 	}
 
-	return &ImageProcessor{
-		width:         width,
-		height:        height,
-		pixels:        pixels,
-		originalPixels: originalPixels,
-	}, nil
+	img, err := codec.decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	ip := newImageProcessorFromImage(img)
+	ip.applyOrientation(orientation)
+	return ip, nil
+}
+
+// SaveOptions controls how SaveImageWithOptions encodes and tags the
+// output file. StripEXIF and NormalizeEXIF only affect JPEG output;
+// they're mutually exclusive, and StripEXIF wins if both are set.
+type SaveOptions struct {
+	Quality       int
+	StripEXIF     bool
+	NormalizeEXIF bool
 }
 
 func (ip *ImageProcessor) SaveImage(path string) error {
-	img := image.NewRGBA(image.Rect(0, 0, ip.width, ip.height))
-	
-	for y := 0; y < ip.height; y++ {
-		for x := 0; x < ip.width; x++ {
-			pixel := ip.pixels[y][x]
-			img.Set(x, y, color.RGBA{pixel.R, pixel.G, pixel.B, pixel.A})
+	return ip.SaveImageWithOptions(path, SaveOptions{Quality: 90})
+}
+
+func (ip *ImageProcessor) SaveImageWithOptions(path string, opts SaveOptions) error {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	if ext == ".gif" && len(ip.frames) > 0 {
+		return ip.saveAnimatedGIF(path)
+	}
+
+	img := pixelsToRGBA(ip.pixels, ip.width, ip.height)
+
+	quality := opts.Quality
+	if quality <= 0 {
+		quality = 90
+	}
+
+	if ext == ".jpg" || ext == ".jpeg" {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return err
 		}
+		data := buf.Bytes()
+		if opts.NormalizeEXIF && !opts.StripEXIF {
+			data = insertEXIFSegment(data, buildEXIFOrientationSegment(defaultOrientation))
+		}
+		return os.WriteFile(path, data, 0644)
+	}
+
+	codec, ok := formatRegistry[ext]
+	if !ok {
+		output, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer output.Close()
+		return jpeg.Encode(output, img, &jpeg.Options{Quality: quality})
+	}
+	if codec.encode == nil {
+		return fmt.Errorf("unsupported output format: %s", ext)
 	}
 
 	output, err := os.Create(path)
@@ -116,16 +166,7 @@ func (ip *ImageProcessor) SaveImage(path string) error {
 		return err
 	}
 	defer output.Close()
-
-	ext := filepath.Ext(path)
-	switch ext {
-	case ".jpg", ".jpeg":
-		return jpeg.Encode(output, img, &jpeg.Options{Quality: 90})
-	case ".png":
-		return png.Encode(output, img)
-	default:
-		return jpeg.Encode(output, img, &jpeg.Options{Quality: 90})
-	}
+	return codec.encode(output, img, quality)
 }
 
 func (ip *ImageProcessor) Grayscale() {
@@ -176,7 +217,7 @@ func (ip *ImageProcessor) Contrast(factor float64) {
 	for y := 0; y < ip.height; y++ {
 		for x := 0; x < ip.width; x++ {
 			pixel := ip.pixels[y][x]
-			
+
 			r := float64(pixel.R)/255.0 - 0.5
 			g := float64(pixel.G)/255.0 - 0.5
 			b := float64(pixel.B)/255.0 - 0.5
@@ -224,29 +265,31 @@ func (ip *ImageProcessor) applyKernel(kernel [][]float64, amount float64) {
 	kernelSize := len(kernel)
 	offset := kernelSize / 2
 
-	for y := offset; y < ip.height-offset; y++ {
-		for x := offset; x < ip.width-offset; x++ {
-			var sumR, sumG, sumB float64
-
-			for ky := 0; ky < kernelSize; ky++ {
-				for kx := 0; kx < kernelSize; kx++ {
-					pixel := ip.pixels[y-offset+ky][x-offset+kx]
-					weight := kernel[ky][kx]
-					sumR += float64(pixel.R) * weight
-					sumG += float64(pixel.G) * weight
-					sumB += float64(pixel.B) * weight
+	parallelRows(ip.height, func(bandStart, bandEnd int) {
+		for y := max(bandStart, offset); y < min(bandEnd, ip.height-offset); y++ {
+			for x := offset; x < ip.width-offset; x++ {
+				var sumR, sumG, sumB float64
+
+				for ky := 0; ky < kernelSize; ky++ {
+					for kx := 0; kx < kernelSize; kx++ {
+						pixel := ip.pixels[y-offset+ky][x-offset+kx]
+						weight := kernel[ky][kx]
+						sumR += float64(pixel.R) * weight
+						sumG += float64(pixel.G) * weight
+						sumB += float64(pixel.B) * weight
+					}
 				}
-			}
 
-			originalPixel := ip.pixels[y][x]
-			result[y][x] = Pixel{
-				R: clamp(float64(originalPixel.R)*(1-amount) + sumR*amount),
-				G: clamp(float64(originalPixel.G)*(1-amount) + sumG*amount),
-				B: clamp(float64(originalPixel.B)*(1-amount) + sumB*amount),
-				A: originalPixel.A,
+				originalPixel := ip.pixels[y][x]
+				result[y][x] = Pixel{
+					R: clamp(float64(originalPixel.R)*(1-amount) + sumR*amount),
+					G: clamp(float64(originalPixel.G)*(1-amount) + sumG*amount),
+					B: clamp(float64(originalPixel.B)*(1-amount) + sumB*amount),
+					A: originalPixel.A,
+				}
 			}
 		}
-	}
+	})
 
 	ip.pixels = result
 }
@@ -290,50 +333,53 @@ func (ip *ImageProcessor) Reset() {
 }
 
 type ProcessingOptions struct {
-	Brightness    float64
-	Contrast      float64
-	Blur          int
-	Sharpen       float64
-	Grayscale     bool
-	Sepia         bool
-	EdgeDetection bool
-	Quality       int
-	Resize        string
+	Brightness        float64
+	Contrast          float64
+	Blur              int
+	Sharpen           float64
+	Grayscale         bool
+	Sepia             bool
+	EdgeDetection     bool
+	Quality           int
+	Resize            string
+	RotateCW          bool
+	RotateCCW         bool
+	FlipHorizontal    bool
+	FlipVertical      bool
+	Thumbnails        string
+	DynamicThumbnails bool
+	BlurHash          bool
+	AutoLevels        bool
+	AutoContrast      bool
+	Gamma             float64
+	HistogramOut      string
+	ProcessAllFrames  bool
 }
 
-func (ip *ImageProcessor) Resize(newWidth, newHeight int) {
-	if newWidth <= 0 || newHeight <= 0 {
-		return
-	}
-
-	result := make([][]Pixel, newHeight)
-	for i := range result {
-		result[i] = make([]Pixel, newWidth)
-	}
-
-	xRatio := float64(ip.width) / float64(newWidth)
-	yRatio := float64(ip.height) / float64(newHeight)
-
-	for y := 0; y < newHeight; y++ {
-		for x := 0; x < newWidth; x++ {
-			px := int(float64(x) * xRatio)
-			py := int(float64(y) * yRatio)
-			result[y][x] = ip.pixels[py][px]
-		}
-	}
-
-	ip.width = newWidth
-	ip.height = newHeight
-	ip.pixels = result
+// ProcessImage applies opts to the image. For an animated GIF loaded
+// with ProcessAllFrames, the same effect chain runs on every frame.
+func (ip *ImageProcessor) ProcessImage(opts ProcessingOptions) {
+	ip.forEachFrame(func(frame *ImageProcessor) {
+		frame.applyEffects(opts)
+	})
 }
 
-func (ip *ImageProcessor) ProcessImage(opts ProcessingOptions) {
+func (ip *ImageProcessor) applyEffects(opts ProcessingOptions) {
 	if opts.Brightness != 1.0 {
 		ip.Brightness(opts.Brightness)
 	}
 	if opts.Contrast != 0 {
 		ip.Contrast(opts.Contrast)
 	}
+	if opts.AutoLevels {
+		ip.AutoLevels()
+	}
+	if opts.AutoContrast {
+		ip.AutoContrast()
+	}
+	if opts.Gamma != 0 {
+		ip.Gamma(opts.Gamma)
+	}
 	if opts.Grayscale {
 		ip.Grayscale()
 	}
@@ -343,6 +389,18 @@ func (ip *ImageProcessor) ProcessImage(opts ProcessingOptions) {
 	if opts.EdgeDetection {
 		ip.EdgeDetection()
 	}
+	if opts.RotateCW {
+		RotateCW(ip)
+	}
+	if opts.RotateCCW {
+		RotateCCW(ip)
+	}
+	if opts.FlipHorizontal {
+		FlipHorizontal(ip)
+	}
+	if opts.FlipVertical {
+		FlipVertical(ip)
+	}
 	if opts.Sharpen != 0 {
 		ip.Sharpen(opts.Sharpen)
 	}
@@ -350,55 +408,120 @@ func (ip *ImageProcessor) ProcessImage(opts ProcessingOptions) {
 		ip.Blur(opts.Blur)
 	}
 	if opts.Resize != "" {
-		parts := strings.Split(opts.Resize, "x")
-		if len(parts) == 2 {
-			width := 0
-			height := 0
-			fmt.Sscanf(parts[0], "%d", &width)
-			fmt.Sscanf(parts[1], "%d", &height)
-			if width > 0 && height > 0 {
-				ip.Resize(width, height)
-			}
+		spec, err := parseResizeSpec(opts.Resize)
+		if err != nil {
+			log.Printf("invalid resize spec %q: %v", opts.Resize, err)
+		} else {
+			ip.ApplyResizeSpec(spec)
 		}
 	}
 }
 
-func processFile(inputPath, outputPath string, opts ProcessingOptions) error {
-	processor, err := NewImageProcessor(inputPath)
+// processFile loads, processes, and saves a single image, returning its
+// BlurHash (empty if opts.BlurHash is false).
+func processFile(inputPath, outputPath string, opts ProcessingOptions) (string, error) {
+	processor, err := NewImageProcessorWithOptions(inputPath, opts.ProcessAllFrames)
 	if err != nil {
-		return fmt.Errorf("error loading image: %v", err)
+		return "", fmt.Errorf("error loading image: %v", err)
+	}
+
+	var preHistogram [3][256]int
+	if opts.HistogramOut != "" {
+		preHistogram = processor.Histogram()
 	}
 
 	processor.ProcessImage(opts)
 
-	return processor.SaveImage(outputPath)
+	if opts.HistogramOut != "" {
+		if err := writeHistogramDebug(opts.HistogramOut, preHistogram, processor.Histogram()); err != nil {
+			return "", err
+		}
+	}
+
+	if err := processor.SaveImage(outputPath); err != nil {
+		return "", err
+	}
+
+	if opts.Thumbnails != "" {
+		specs, err := parseThumbnailSpecs(opts.Thumbnails)
+		if err != nil {
+			return "", err
+		}
+		if err := processor.GenerateThumbnails(filepath.Base(inputPath), specs, filepath.Dir(outputPath), opts.DynamicThumbnails); err != nil {
+			return "", err
+		}
+	}
+
+	if !opts.BlurHash {
+		return "", nil
+	}
+	return processor.BlurHash(blurHashXComponents, blurHashYComponents)
 }
 
-func processBatch(inputDir, outputDir string, opts ProcessingOptions) error {
+func processBatch(inputDir, outputDir string, opts ProcessingOptions, workers int, blurHashOut string) error {
 	files, err := os.ReadDir(inputDir)
 	if err != nil {
 		return err
 	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	names := make(chan string, len(files))
+	errs := make(chan error, len(files))
+
+	var hashesMu sync.Mutex
+	hashes := make(map[string]string)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				inputPath := filepath.Join(inputDir, name)
+				outputPath := filepath.Join(outputDir, name)
+
+				hash, err := processFile(inputPath, outputPath, opts)
+				if err != nil {
+					errs <- fmt.Errorf("%s: %v", name, err)
+					continue
+				}
+
+				fmt.Printf("Processed: %s -> %s\n", name, outputPath)
+
+				if opts.BlurHash {
+					hashesMu.Lock()
+					hashes[name] = hash
+					hashesMu.Unlock()
+				}
+			}
+		}()
+	}
 
 	for _, file := range files {
 		if file.IsDir() {
 			continue
 		}
-
 		ext := strings.ToLower(filepath.Ext(file.Name()))
-		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+		if _, ok := formatRegistry[ext]; !ok {
 			continue
 		}
+		names <- file.Name()
+	}
+	close(names)
 
-		inputPath := filepath.Join(inputDir, file.Name())
-		outputPath := filepath.Join(outputDir, file.Name())
+	wg.Wait()
+	close(errs)
 
-		if err := processFile(inputPath, outputPath, opts); err != nil {
-			log.Printf("Error processing %s: %v", file.Name(), err)
-			continue
-		}
+	for err := range errs {
+		log.Printf("Error processing %v", err)
+	}
 
-		fmt.Printf("Processed: %s -> %s\n", file.Name(), outputPath)
+	if opts.BlurHash && blurHashOut != "" {
+		if err := writeBlurHashSidecar(blurHashOut, hashes); err != nil {
+			return fmt.Errorf("error writing blurhash sidecar: %v", err)
+		}
 	}
 	return nil
 }
@@ -416,7 +539,21 @@ func main() {
 	flag.BoolVar(&opts.Sepia, "sepia", false, "Apply sepia effect")
 	flag.BoolVar(&opts.EdgeDetection, "edge", false, "Apply edge detection")
 	flag.IntVar(&opts.Quality, "quality", 90, "JPEG output quality (0-100)")
-	flag.StringVar(&opts.Resize, "resize", "", "Resize image (e.g., 800x600)")
+	flag.StringVar(&opts.Resize, "resize", "", "Resize image, e.g. 800x600, 800x0 (preserve aspect), or 800x600:lanczos:fill")
+	flag.BoolVar(&opts.RotateCW, "rotate-cw", false, "Rotate 90 degrees clockwise")
+	flag.BoolVar(&opts.RotateCCW, "rotate-ccw", false, "Rotate 90 degrees counter-clockwise")
+	flag.BoolVar(&opts.FlipHorizontal, "flip-h", false, "Flip horizontally")
+	flag.BoolVar(&opts.FlipVertical, "flip-v", false, "Flip vertically")
+	flag.StringVar(&opts.Thumbnails, "thumbnails", "", "Generate thumbnail set, e.g. 32x32:crop,96x96:crop,640x480:scale")
+	flag.BoolVar(&opts.DynamicThumbnails, "dynamic-thumbnails", false, "Only generate thumbnail sizes missing from the output directory")
+	workers := flag.Int("workers", runtime.NumCPU(), "Number of concurrent workers for batch processing")
+	flag.BoolVar(&opts.BlurHash, "blurhash", false, "Compute a BlurHash placeholder for each image")
+	blurHashOut := flag.String("blurhash-out", "", "Write a JSON sidecar mapping filename to BlurHash (batch mode)")
+	flag.BoolVar(&opts.AutoLevels, "auto-levels", false, "Stretch each channel's 1st/99th percentile to 0/255")
+	flag.BoolVar(&opts.AutoContrast, "auto-contrast", false, "Stretch luminance's 1st/99th percentile to 0/255")
+	flag.Float64Var(&opts.Gamma, "gamma", 0, "Gamma correction (e.g. 2.2); 0 disables")
+	flag.StringVar(&opts.HistogramOut, "histogram", "", "Write pre/post-processing histograms as JSON to this file")
+	flag.BoolVar(&opts.ProcessAllFrames, "process-all-frames", false, "Apply effects to every frame of an animated GIF instead of just the first")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "GoLens - Image Processing Tool\n\n")
@@ -445,15 +582,25 @@ func main() {
 			log.Fatalf("Error creating output directory: %v", err)
 		}
 		fmt.Println("Starting batch processing...")
-		if err := processBatch(*input, *output, opts); err != nil {
+		if err := processBatch(*input, *output, opts, *workers, *blurHashOut); err != nil {
 			log.Fatalf("Batch processing failed: %v", err)
 		}
 		fmt.Println("Batch processing completed successfully!")
 	} else {
 		fmt.Println("Processing single file...")
-		if err := processFile(*input, *output, opts); err != nil {
+		hash, err := processFile(*input, *output, opts)
+		if err != nil {
 			log.Fatalf("Processing failed: %v", err)
 		}
 		fmt.Println("File processed successfully!")
+		if opts.BlurHash {
+			if *blurHashOut != "" {
+				if err := writeBlurHashSidecar(*blurHashOut, map[string]string{filepath.Base(*input): hash}); err != nil {
+					log.Printf("Error writing blurhash sidecar: %v", err)
+				}
+			} else {
+				fmt.Printf("BlurHash: %s\n", hash)
+			}
+		}
 	}
 }
@@ -39,7 +39,7 @@ func TestImageProcessor(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			outputPath := filepath.Join(t.TempDir(), "output.jpg")
-			err := processFile(testImg, outputPath, tt.opts)
+			_, err := processFile(testImg, outputPath, tt.opts)
 			if err != nil {
 				t.Errorf("processFile() error = %v", err)
 				return
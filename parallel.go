@@ -0,0 +1,36 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parallelRows splits [0, total) into up to runtime.NumCPU() contiguous
+// bands and runs fn once per band concurrently, blocking until every
+// band finishes. Row-wise image operations are embarrassingly parallel
+// since each output row only reads from (and writes to) its own band.
+func parallelRows(total int, fn func(start, end int)) {
+	workers := runtime.NumCPU()
+	if workers > total {
+		workers = total
+	}
+	if workers < 1 {
+		fn(0, total)
+		return
+	}
+
+	band := (total + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < total; start += band {
+		end := start + band
+		if end > total {
+			end = total
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			fn(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestParseResizeSpec(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       string
+		wantWidth  int
+		wantHeight int
+		wantFilter ResampleFilter
+		wantMode   ResizeMode
+		wantErr    bool
+	}{
+		{name: "WxH", spec: "800x600", wantWidth: 800, wantHeight: 600, wantFilter: CatmullRom, wantMode: ResizeFit},
+		{name: "zero height preserves aspect later", spec: "800x0", wantWidth: 800, wantHeight: 0, wantFilter: CatmullRom, wantMode: ResizeFit},
+		{name: "filter and mode options", spec: "800x600:lanczos:fill", wantWidth: 800, wantHeight: 600, wantFilter: Lanczos3, wantMode: ResizeFill},
+		{name: "missing x separator", spec: "800600", wantErr: true},
+		{name: "non-numeric width", spec: "abcx600", wantErr: true},
+		{name: "both dimensions zero", spec: "0x0", wantErr: true},
+		{name: "unrecognized option", spec: "800x600:bogus", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseResizeSpec(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseResizeSpec(%q) error = nil, want error", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseResizeSpec(%q) error = %v", tt.spec, err)
+			}
+			if got.Width != tt.wantWidth || got.Height != tt.wantHeight {
+				t.Errorf("parseResizeSpec(%q) dims = %dx%d, want %dx%d", tt.spec, got.Width, got.Height, tt.wantWidth, tt.wantHeight)
+			}
+			if got.Filter != tt.wantFilter {
+				t.Errorf("parseResizeSpec(%q) filter = %#v, want %#v", tt.spec, got.Filter, tt.wantFilter)
+			}
+			if got.Mode != tt.wantMode {
+				t.Errorf("parseResizeSpec(%q) mode = %v, want %v", tt.spec, got.Mode, tt.wantMode)
+			}
+		})
+	}
+}
+
+func newTestProcessor(width, height int) *ImageProcessor {
+	pixels := make([][]Pixel, height)
+	for y := range pixels {
+		pixels[y] = make([]Pixel, width)
+		for x := range pixels[y] {
+			pixels[y][x] = Pixel{R: uint8(x % 256), G: uint8(y % 256), B: 0, A: 255}
+		}
+	}
+	return &ImageProcessor{width: width, height: height, pixels: pixels}
+}
+
+func TestApplyResizeSpecFitVsFill(t *testing.T) {
+	// 200x100 source into a 50x50 box: Fit should shrink to fit entirely
+	// inside the box (width-limited, so 50x25); Fill should cover the
+	// box and crop to exactly 50x50.
+	fit := newTestProcessor(200, 100)
+	fit.ApplyResizeSpec(ResizeSpec{Width: 50, Height: 50, Filter: Linear, Mode: ResizeFit})
+	if fit.width != 50 || fit.height != 25 {
+		t.Errorf("Fit size = %dx%d, want 50x25", fit.width, fit.height)
+	}
+
+	fill := newTestProcessor(200, 100)
+	fill.ApplyResizeSpec(ResizeSpec{Width: 50, Height: 50, Filter: Linear, Mode: ResizeFill})
+	if fill.width != 50 || fill.height != 50 {
+		t.Errorf("Fill size = %dx%d, want 50x50", fill.width, fill.height)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ThumbnailMethod mirrors the "preset size + method" design common to
+// media servers: Scale fits the image inside the box (it may end up
+// smaller than the box on one axis), Crop fills the box exactly and
+// crops the overflow.
+type ThumbnailMethod int
+
+const (
+	ThumbnailScale ThumbnailMethod = iota
+	ThumbnailCrop
+)
+
+// ThumbnailSpec describes one preset thumbnail size.
+type ThumbnailSpec struct {
+	Width  int
+	Height int
+	Method ThumbnailMethod
+}
+
+// String renders the spec the way thumbnailFileName expects to parse
+// it back, e.g. "96x96:crop".
+func (s ThumbnailSpec) String() string {
+	method := "scale"
+	if s.Method == ThumbnailCrop {
+		method = "crop"
+	}
+	return fmt.Sprintf("%dx%d:%s", s.Width, s.Height, method)
+}
+
+// Thumbnail returns a new ImageProcessor holding the image resized to
+// spec, leaving ip untouched.
+func (ip *ImageProcessor) Thumbnail(spec ThumbnailSpec) *ImageProcessor {
+	mode := ResizeFit
+	if spec.Method == ThumbnailCrop {
+		mode = ResizeFill
+	}
+
+	result := ip.Clone()
+	result.ApplyResizeSpec(ResizeSpec{
+		Width:  spec.Width,
+		Height: spec.Height,
+		Filter: CatmullRom,
+		Mode:   mode,
+	})
+	return result
+}
+
+// thumbnailFileName builds "name_WxH.jpg" for a given source file name
+// and spec, discarding the source extension in favor of JPEG.
+func thumbnailFileName(srcName string, spec ThumbnailSpec) string {
+	base := strings.TrimSuffix(srcName, filepath.Ext(srcName))
+	return fmt.Sprintf("%s_%dx%d.jpg", base, spec.Width, spec.Height)
+}
+
+// GenerateThumbnails writes one JPEG per spec into outDir, named
+// "name_WxH.jpg". Missing thumbnails only (per generateMissing) are
+// produced when dynamic generation is enabled.
+func (ip *ImageProcessor) GenerateThumbnails(srcName string, specs []ThumbnailSpec, outDir string, onlyMissing bool) error {
+	for _, spec := range specs {
+		outPath := filepath.Join(outDir, thumbnailFileName(srcName, spec))
+
+		if onlyMissing {
+			if _, err := os.Stat(outPath); err == nil {
+				continue
+			}
+		}
+
+		thumb := ip.Thumbnail(spec)
+		if err := thumb.SaveImage(outPath); err != nil {
+			return fmt.Errorf("error generating thumbnail %s: %v", outPath, err)
+		}
+	}
+	return nil
+}
+
+// parseThumbnailSpecs parses a "-thumbnails" flag value, a comma
+// separated list of "WxH:method" entries, e.g.
+// "32x32:crop,96x96:crop,640x480:scale".
+func parseThumbnailSpecs(value string) ([]ThumbnailSpec, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var specs []ThumbnailSpec
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		dims := strings.Split(parts[0], "x")
+		if len(dims) != 2 {
+			return nil, fmt.Errorf("thumbnails: invalid dimensions %q", parts[0])
+		}
+		width, err := strconv.Atoi(dims[0])
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("thumbnails: invalid width %q", dims[0])
+		}
+		height, err := strconv.Atoi(dims[1])
+		if err != nil || height <= 0 {
+			return nil, fmt.Errorf("thumbnails: invalid height %q", dims[1])
+		}
+
+		method := ThumbnailScale
+		if len(parts) > 1 {
+			switch strings.ToLower(parts[1]) {
+			case "scale":
+				method = ThumbnailScale
+			case "crop":
+				method = ThumbnailCrop
+			default:
+				return nil, fmt.Errorf("thumbnails: unrecognized method %q", parts[1])
+			}
+		}
+
+		specs = append(specs, ThumbnailSpec{Width: width, Height: height, Method: method})
+	}
+	return specs, nil
+}